@@ -0,0 +1,47 @@
+package airbrakehandler
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/airbrake/gobrake"
+)
+
+// TestNewFromNotifier_TransportClonesSharedClient guards against regressing into mutating
+// gobrake's package-level default *http.Client, which every notifier that isn't given its own
+// HTTPClient shares.
+func TestNewFromNotifier_TransportClonesSharedClient(t *testing.T) {
+	notifierA := gobrake.NewNotifier(1, "key")
+	sharedClient := notifierA.Client
+
+	notifierB := gobrake.NewNotifier(2, "key")
+
+	NewFromNotifier(notifierA, Transport(http.DefaultTransport))
+
+	if notifierA.Client == sharedClient {
+		t.Fatal("expected Transport to clone the shared client instead of mutating it in place")
+	}
+
+	if notifierB.Client.Transport == http.DefaultTransport {
+		t.Fatal("Transport leaked into another notifier sharing gobrake's default client")
+	}
+}
+
+// TestNew_ConcurrentTransportIsRaceFree reproduces the data race a shared, mutated-in-place
+// client caused when multiple handlers were constructed with Transport concurrently.
+func TestNew_ConcurrentTransportIsRaceFree(t *testing.T) {
+	var wg sync.WaitGroup
+
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			New(1, "key", Transport(http.DefaultTransport))
+		}()
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,102 @@
+package airbrakehandler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/airbrake/gobrake"
+	"github.com/stretchr/testify/assert"
+)
+
+func newNotice() *gobrake.Notice {
+	return &gobrake.Notice{
+		Context: map[string]interface{}{},
+		Session: map[string]interface{}{},
+		Params:  map[string]interface{}{},
+	}
+}
+
+func TestHandler_filter_Environment(t *testing.T) {
+	h := &Handler{environment: "production", revision: "1234567"}
+
+	notice := h.filter(newNotice())
+
+	assert.Equal(t, "production", notice.Context["environment"])
+	assert.Equal(t, "1234567", notice.Context["revision"])
+}
+
+func TestHandler_filter_SessionFilter(t *testing.T) {
+	h := &Handler{sessionFilter: []string{"token"}}
+
+	notice := newNotice()
+	notice.Session["token"] = "secret"
+	notice.Session["user_id"] = 42
+
+	h.filter(notice)
+
+	assert.NotContains(t, notice.Session, "token")
+	assert.Equal(t, 42, notice.Session["user_id"])
+}
+
+func TestHandler_filter_ParamsFilter(t *testing.T) {
+	h := &Handler{paramsFilter: []string{"password"}}
+
+	notice := newNotice()
+	notice.Params["password"] = "hunter2"
+	notice.Params["user_id"] = 42
+
+	h.filter(notice)
+
+	assert.NotContains(t, notice.Params, "password")
+	assert.Equal(t, 42, notice.Params["user_id"])
+}
+
+// TestHandler_filter_IgnoreFunc proves ignore also applies to notices that never go through
+// handle, eg. ones sent directly on the *gobrake.Notifier passed to NewFromNotifier.
+func TestHandler_filter_IgnoreFunc(t *testing.T) {
+	h := &Handler{
+		ignoreFuncs: []IgnoreFunc{func(err error) bool {
+			return strings.Contains(err.Error(), "ignore me")
+		}},
+	}
+
+	notice := newNotice()
+	notice.Errors = []gobrake.Error{{Message: "please ignore me"}}
+
+	assert.Nil(t, h.filter(notice))
+}
+
+func TestHandler_filter_SeverityFunc(t *testing.T) {
+	h := &Handler{
+		severityFuncs: []SeverityFunc{func(err error) string {
+			if strings.Contains(err.Error(), "fatal") {
+				return "critical"
+			}
+
+			return ""
+		}},
+	}
+
+	notice := newNotice()
+	notice.Errors = []gobrake.Error{{Message: "fatal disk error"}}
+
+	notice = h.filter(notice)
+
+	assert.Equal(t, "critical", notice.Context["severity"])
+}
+
+// TestHandler_filter_SeverityFunc_DoesNotOverrideExisting ensures RecoverPanic's forced
+// "critical" severity (set on the notice before filter runs) isn't overridden by a configured
+// SeverityFunc.
+func TestHandler_filter_SeverityFunc_DoesNotOverrideExisting(t *testing.T) {
+	h := &Handler{
+		severityFuncs: []SeverityFunc{func(error) string { return "warning" }},
+	}
+
+	notice := newNotice()
+	notice.Context["severity"] = "critical"
+
+	notice = h.filter(notice)
+
+	assert.Equal(t, "critical", notice.Context["severity"])
+}
@@ -27,16 +27,72 @@ If you want to Flush notices you can do it as you would with Gobrake's notifier
 or you can configure the handler to send notices synchronously:
 
 	handler := airbrakehandler.NewFromNotifier(notifier, airbrakehandler.SendSynchronously(true))
+
+Notices can be tagged with an environment and a revision, so that errors can be segmented
+per environment and correlated to a deploy:
+
+	handler := airbrakehandler.New(
+		projectID,
+		projectKey,
+		airbrakehandler.Environment("production"),
+		airbrakehandler.Revision("1234567"),
+	)
+
+	handler.NotifyDeploy("production", "1234567", "github.com/goph/emperror", "john.doe")
+
+Users pointing at a self-hosted Errbit instance (or running behind a proxy/custom TLS config)
+can configure the host and HTTP client without dropping down to NewFromNotifier:
+
+	handler := airbrakehandler.New(
+		projectID,
+		projectKey,
+		airbrakehandler.Host("https://errbit.internal"),
+		airbrakehandler.HTTPClient(client),
+	)
+
+For production workloads, Async replaces Gobrake's fire-and-forget async sender with a
+bounded worker pool, so that a burst of errors can't grow without bound and callers get told
+about notices that had to be dropped:
+
+	handler := airbrakehandler.New(
+		projectID,
+		projectKey,
+		airbrakehandler.Async(4, 1000),
+		airbrakehandler.OnDrop(func(err error) {
+			log.Println("dropped airbrake notice:", err)
+		}),
+		airbrakehandler.FlushTimeout(5*time.Second),
+	)
+
+	defer handler.Close()
 */
 package airbrakehandler
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
 	"github.com/airbrake/gobrake"
 	"github.com/goph/emperror"
 	"github.com/goph/emperror/httperr"
 	"github.com/goph/emperror/internal/keyvals"
 )
 
+// defaultHost is the Airbrake host used when no Host option is configured.
+const defaultHost = "https://api.airbrake.io"
+
+// deployURL is the deploy tracking endpoint relative to a host.
+const deployURL = "%s/api/v4/projects/%d/deploys?key=%s"
+
+// errQueueFull is passed to OnDrop when a notice is dropped because the async queue is full.
+var errQueueFull = errors.New("airbrakehandler: async queue is full")
+
 // Option configures a logger instance.
 type Option interface {
 	apply(*Handler)
@@ -49,16 +105,220 @@ func (o SendSynchronously) apply(l *Handler) {
 	l.sendAsynchronously = bool(o)
 }
 
+// Environment sets the environment (eg. "production", "staging") notices are reported from.
+// It is attached to every notice sent through the handler.
+type Environment string
+
+func (o Environment) apply(h *Handler) {
+	h.environment = string(o)
+}
+
+// Revision sets the VCS revision (commit SHA) notices and deploys are associated with.
+type Revision string
+
+func (o Revision) apply(h *Handler) {
+	h.revision = string(o)
+}
+
+// Repository sets the VCS repository URL deploys are associated with.
+type Repository string
+
+func (o Repository) apply(h *Handler) {
+	h.repository = string(o)
+}
+
+// IgnoreFunc registers a predicate that, when it returns true for an error, prevents that
+// error from being reported to Airbrake. Multiple IgnoreFunc options can be registered;
+// an error is ignored if any of them matches it.
+type IgnoreFunc func(error) bool
+
+func (o IgnoreFunc) apply(h *Handler) {
+	h.ignoreFuncs = append(h.ignoreFuncs, o)
+}
+
+// SeverityFunc maps an error to an Airbrake severity ("critical", "error", "warning" or "info").
+// Registered SeverityFunc options are tried in order and the first non-empty result wins.
+type SeverityFunc func(error) string
+
+func (o SeverityFunc) apply(h *Handler) {
+	h.severityFuncs = append(h.severityFuncs, o)
+}
+
+// ParamsFilter redacts the given keys (eg. "authorization", "password") from notice.Params.
+type ParamsFilter []string
+
+func (o ParamsFilter) apply(h *Handler) {
+	h.paramsFilter = append(h.paramsFilter, o...)
+}
+
+// SessionFilter redacts the given keys from notice.Session.
+type SessionFilter []string
+
+func (o SessionFilter) apply(h *Handler) {
+	h.sessionFilter = append(h.sessionFilter, o...)
+}
+
+// Host sets the host notices and deploys are sent to, eg. "https://errbit.internal" for a
+// self-hosted Errbit instance. For notices it only has an effect when used with New, since
+// NewFromNotifier is handed an already constructed notifier; NotifyDeploy uses it either way.
+type Host string
+
+func (o Host) apply(h *Handler) {
+	h.host = string(o)
+}
+
+// HTTPClient replaces the HTTP client the underlying notifier uses to talk to Airbrake/Errbit,
+// eg. to route through a corporate proxy or use a custom TLS config.
+func HTTPClient(client *http.Client) Option {
+	return httpClientOption{client: client}
+}
+
+type httpClientOption struct {
+	client *http.Client
+}
+
+func (o httpClientOption) apply(h *Handler) {
+	h.httpClient = o.client
+}
+
+// Transport sets the RoundTripper used by the notifier's HTTP client. It is applied after every
+// other option has run, so it takes effect regardless of whether it is registered before or
+// after HTTPClient.
+func Transport(transport http.RoundTripper) Option {
+	return transportOption{transport: transport}
+}
+
+type transportOption struct {
+	transport http.RoundTripper
+}
+
+func (o transportOption) apply(h *Handler) {
+	h.transport = o.transport
+}
+
+// Async configures the handler to send notices through a bounded pool of workers goroutines
+// instead of gobrake's fire-and-forget async sender, giving callers backpressure visibility
+// through OnDrop and Stats. workers controls how many notices can be sent concurrently and
+// queueSize how many can be buffered before OnDrop is invoked and the notice is dropped. workers
+// is clamped to at least 1, so that Async always actually starts the worker pool, and queueSize
+// is clamped to at least 0, rather than panicking on a negative value.
+func Async(workers int, queueSize int) Option {
+	if workers < 1 {
+		workers = 1
+	}
+
+	if queueSize < 0 {
+		queueSize = 0
+	}
+
+	return asyncOption{workers: workers, queueSize: queueSize}
+}
+
+type asyncOption struct {
+	workers   int
+	queueSize int
+}
+
+func (o asyncOption) apply(h *Handler) {
+	h.asyncWorkers = o.workers
+	h.asyncQueueSize = o.queueSize
+}
+
+// OnDropFunc is invoked whenever a notice could not be delivered: the async queue configured
+// via Async was full, or the underlying notifier refused it (eg. because Airbrake rate
+// limited the project). Notices handed to gobrake's own async sender (NewAsync,
+// NewAsyncFromNotifier) are not reported to it.
+type OnDropFunc func(error)
+
+// OnDrop registers the callback invoked whenever a notice is dropped.
+func OnDrop(f OnDropFunc) Option {
+	return onDropOption(f)
+}
+
+type onDropOption OnDropFunc
+
+func (o onDropOption) apply(h *Handler) {
+	h.onDrop = OnDropFunc(o)
+}
+
+// FlushTimeout bounds how long Close waits for the Async queue to drain before returning.
+// A zero FlushTimeout (the default) makes Close wait until the queue is fully drained.
+type FlushTimeout time.Duration
+
+func (o FlushTimeout) apply(h *Handler) {
+	h.flushTimeout = time.Duration(o)
+}
+
+// Stats reports the handler's Async counters.
+type Stats struct {
+	// Sent is the number of notices successfully handed off to Airbrake.
+	Sent uint64
+
+	// Dropped is the number of notices that were discarded, either because the async queue
+	// was full or the underlying notifier refused them.
+	Dropped uint64
+
+	// Queued is the number of notices currently buffered in the async queue.
+	Queued int
+}
+
 // Handler is responsible for sending errors to Airbrake/Errbit.
 type Handler struct {
 	notifier *gobrake.Notifier
 
+	projectID  int64
+	projectKey string
+
+	environment string
+	revision    string
+	repository  string
+
+	ignoreFuncs   []IgnoreFunc
+	severityFuncs []SeverityFunc
+	paramsFilter  []string
+	sessionFilter []string
+
+	host       string
+	httpClient *http.Client
+	transport  http.RoundTripper
+
 	sendAsynchronously bool
+
+	asyncWorkers   int
+	asyncQueueSize int
+	queue          chan *gobrake.Notice
+	wg             sync.WaitGroup
+	closeMu        sync.RWMutex
+	closeOnce      sync.Once
+	closed         bool
+	onDrop         OnDropFunc
+	flushTimeout   time.Duration
+	sent           uint64
+	dropped        uint64
 }
 
 // New creates a new Airbrake handler.
 func New(projectID int64, projectKey string, opts ...Option) *Handler {
-	return NewFromNotifier(gobrake.NewNotifier(projectID, projectKey), opts...)
+	var host string
+
+	for _, o := range opts {
+		if h, ok := o.(Host); ok {
+			host = string(h)
+		}
+	}
+
+	notifier := gobrake.NewNotifierWithOptions(&gobrake.NotifierOptions{
+		ProjectId:  projectID,
+		ProjectKey: projectKey,
+		Host:       host,
+	})
+
+	h := NewFromNotifier(notifier, opts...)
+
+	h.projectID = projectID
+	h.projectKey = projectKey
+
+	return h
 }
 
 // NewAsync creates a new Airbrake handler that sends errors asynchronously.
@@ -80,6 +340,33 @@ func NewFromNotifier(notifier *gobrake.Notifier, opts ...Option) *Handler {
 		o.apply(h)
 	}
 
+	// Applied after every option has run so that HTTPClient and Transport take effect
+	// regardless of the order they were passed in.
+	if h.httpClient != nil {
+		h.notifier.Client = h.httpClient
+	}
+
+	if h.transport != nil {
+		if h.httpClient == nil {
+			// Unless HTTPClient was used, h.notifier.Client may be gobrake's package-level
+			// default *http.Client, shared by every notifier that doesn't get its own.
+			// Clone it before mutating so Transport doesn't leak into other notifiers.
+			client := *h.notifier.Client
+			h.notifier.Client = &client
+		}
+
+		h.notifier.Client.Transport = h.transport
+	}
+
+	if h.environment != "" || h.revision != "" || len(h.paramsFilter) > 0 || len(h.sessionFilter) > 0 ||
+		len(h.ignoreFuncs) > 0 || len(h.severityFuncs) > 0 {
+		h.notifier.AddFilter(h.filter)
+	}
+
+	if h.asyncWorkers > 0 {
+		h.startWorkers()
+	}
+
 	return h
 }
 
@@ -92,8 +379,156 @@ func NewAsyncFromNotifier(notifier *gobrake.Notifier, opts ...Option) *Handler {
 	return h
 }
 
+// startWorkers spins up the Async worker pool and its bounded queue.
+func (h *Handler) startWorkers() {
+	h.queue = make(chan *gobrake.Notice, h.asyncQueueSize)
+
+	for i := 0; i < h.asyncWorkers; i++ {
+		h.wg.Add(1)
+
+		go h.worker()
+	}
+}
+
+// worker sends queued notices until the queue is closed.
+func (h *Handler) worker() {
+	defer h.wg.Done()
+
+	for notice := range h.queue {
+		h.send(notice)
+	}
+}
+
+// send delivers notice synchronously and updates the Sent/Dropped counters, invoking OnDrop
+// on failure.
+func (h *Handler) send(notice *gobrake.Notice) {
+	if _, err := h.notifier.SendNotice(notice); err != nil {
+		atomic.AddUint64(&h.dropped, 1)
+
+		if h.onDrop != nil {
+			h.onDrop(err)
+		}
+
+		return
+	}
+
+	atomic.AddUint64(&h.sent, 1)
+}
+
+// Stats returns a snapshot of the handler's Async counters.
+func (h *Handler) Stats() Stats {
+	return Stats{
+		Sent:    atomic.LoadUint64(&h.sent),
+		Dropped: atomic.LoadUint64(&h.dropped),
+		Queued:  len(h.queue),
+	}
+}
+
+// enqueue buffers notice for the Async workers, returning false (without sending it) if the
+// queue is full or the handler has already been closed.
+func (h *Handler) enqueue(notice *gobrake.Notice) bool {
+	h.closeMu.RLock()
+	defer h.closeMu.RUnlock()
+
+	if h.closed {
+		return false
+	}
+
+	select {
+	case h.queue <- notice:
+		return true
+	default:
+		return false
+	}
+}
+
+// filter is the single gobrake filter the handler registers. It applies the ignore and
+// severity pipelines, tags the notice with the configured environment and revision, and
+// redacts the keys configured via ParamsFilter and SessionFilter. Running this as a gobrake
+// filter, rather than special-casing ignore/severity in handle, means it also applies to
+// notices sent through the underlying *gobrake.Notifier directly, eg. via NewFromNotifier.
+func (h *Handler) filter(notice *gobrake.Notice) *gobrake.Notice {
+	err := errorFromNotice(notice)
+
+	if h.shouldIgnore(err) {
+		return nil
+	}
+
+	if h.environment != "" {
+		notice.Context["environment"] = h.environment
+	}
+
+	if h.revision != "" {
+		notice.Context["revision"] = h.revision
+	}
+
+	if _, ok := notice.Context["severity"]; !ok {
+		if severity := h.severity(err); severity != "" {
+			notice.Context["severity"] = severity
+		}
+	}
+
+	for _, key := range h.paramsFilter {
+		delete(notice.Params, key)
+	}
+
+	for _, key := range h.sessionFilter {
+		delete(notice.Session, key)
+	}
+
+	return notice
+}
+
+// errorFromNotice reconstructs a representative error from a notice's reported errors. filter
+// uses it to run the ignore and severity pipelines against notices that never went through
+// handle (eg. sent directly on the underlying *gobrake.Notifier), where the original error
+// value isn't available.
+func errorFromNotice(notice *gobrake.Notice) error {
+	if len(notice.Errors) == 0 {
+		return errors.New("")
+	}
+
+	return errors.New(notice.Errors[0].Message)
+}
+
+// shouldIgnore reports whether err matches any of the registered IgnoreFunc options.
+func (h *Handler) shouldIgnore(err error) bool {
+	for _, ignore := range h.ignoreFuncs {
+		if ignore(err) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// severity returns the severity the registered SeverityFunc options map err to, or
+// an empty string if none of them matched.
+func (h *Handler) severity(err error) string {
+	for _, severityFunc := range h.severityFuncs {
+		if severity := severityFunc(err); severity != "" {
+			return severity
+		}
+	}
+
+	return ""
+}
+
 // Handle calls the underlying Airbrake notifier.
 func (h *Handler) Handle(err error) {
+	h.handle(err, "", false)
+}
+
+// handle is the shared implementation behind Handle and RecoverPanic. severity, when
+// non-empty, overrides the result of the SeverityFunc pipeline (which filter applies), and
+// forceSync sends the notice synchronously regardless of SendSynchronously.
+func (h *Handler) handle(err error, severity string, forceSync bool) {
+	// Not strictly necessary since filter also ignores matching errors, but avoids the cost of
+	// building a notice (stack trace, context) for one that is going to be dropped anyway.
+	if h.shouldIgnore(err) {
+		return
+	}
+
 	// Get HTTP request (if any)
 	req, _ := httperr.HTTPRequest(err)
 
@@ -107,14 +542,143 @@ func (h *Handler) Handle(err error) {
 		notice.Params = keyvals.ToMap(kvs)
 	}
 
-	if h.sendAsynchronously {
+	if severity != "" {
+		notice.Context["severity"] = severity
+	}
+
+	switch {
+	case forceSync:
+		h.send(notice)
+	case h.queue != nil:
+		if !h.enqueue(notice) {
+			atomic.AddUint64(&h.dropped, 1)
+
+			if h.onDrop != nil {
+				h.onDrop(errQueueFull)
+			}
+		}
+	case h.sendAsynchronously:
 		h.notifier.SendNoticeAsync(notice)
-	} else {
-		_, _ = h.notifier.SendNotice(notice)
+	default:
+		h.send(notice)
 	}
 }
 
-// Close closes the underlying Airbrake instance.
+// Close closes the underlying Airbrake instance, waiting for the Async queue (if any) to
+// drain first, up to FlushTimeout. It is safe to call more than once.
 func (h *Handler) Close() error {
+	if h.queue != nil {
+		h.closeOnce.Do(func() {
+			h.closeMu.Lock()
+			h.closed = true
+			close(h.queue)
+			h.closeMu.Unlock()
+		})
+
+		drained := make(chan struct{})
+
+		go func() {
+			h.wg.Wait()
+			close(drained)
+		}()
+
+		if h.flushTimeout > 0 {
+			select {
+			case <-drained:
+			case <-time.After(h.flushTimeout):
+			}
+		} else {
+			<-drained
+		}
+	}
+
 	return h.notifier.Close()
 }
+
+// RecoverOption configures RecoverPanic.
+type RecoverOption interface {
+	apply(*recoverConfig)
+}
+
+type recoverConfig struct {
+	flushSync bool
+}
+
+// FlushSync makes RecoverPanic send the notice synchronously before returning, so that a
+// panic that goes on to crash the process does not take the notice with it.
+type FlushSync bool
+
+func (o FlushSync) apply(c *recoverConfig) {
+	c.flushSync = bool(o)
+}
+
+// RecoverPanic is an airbrakehandler-aware alternative to emperror.HandleRecover. Deferred at
+// the top of a goroutine or HTTP handler, it reports a recovered panic to handler with
+// "critical" severity. Non-error panic values are wrapped with errors.New(fmt.Sprint(v)). If
+// the recovered value carries an *http.Request (eg. because it was wrapped with
+// httperr.WithHTTPRequest before being passed to panic), that request is attached to the
+// notice the same way Handle attaches it. Like emperror.HandleRecover, it swallows the panic.
+func RecoverPanic(handler *Handler, opts ...RecoverOption) {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	var c recoverConfig
+	for _, o := range opts {
+		o.apply(&c)
+	}
+
+	err, ok := r.(error)
+	if !ok {
+		err = errors.New(fmt.Sprint(r))
+	}
+
+	handler.handle(err, "critical", c.flushSync)
+}
+
+// NotifyDeploy notifies Airbrake about a deploy, so that error spikes can be correlated to it.
+// env, rev and repo default to the handler's configured Environment, Revision and Repository
+// (set via New's options) when left empty.
+func (h *Handler) NotifyDeploy(env string, rev string, repo string, user string) error {
+	if env == "" {
+		env = h.environment
+	}
+
+	if rev == "" {
+		rev = h.revision
+	}
+
+	if repo == "" {
+		repo = h.repository
+	}
+
+	body, err := json.Marshal(map[string]string{
+		"environment": env,
+		"revision":    rev,
+		"repository":  repo,
+		"username":    user,
+	})
+	if err != nil {
+		return err
+	}
+
+	host := h.host
+	if host == "" {
+		host = defaultHost
+	}
+
+	url := fmt.Sprintf(deployURL, host, h.projectID, h.projectKey)
+
+	resp, err := h.notifier.Client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("airbrakehandler: deploy notification failed with status %d", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,372 @@
+package airbrakehandler_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/goph/emperror"
+	"github.com/goph/emperror/handler/airbrakehandler"
+	"github.com/stretchr/testify/assert"
+)
+
+func notifyServer(t *testing.T, notice *map[string]interface{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(notice))
+
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id":"1"}`))
+	}))
+}
+
+func TestRecoverPanic_Error(t *testing.T) {
+	var notice map[string]interface{}
+
+	server := notifyServer(t, &notice)
+	defer server.Close()
+
+	handler := airbrakehandler.New(1, "key", airbrakehandler.Host(server.URL))
+
+	func() {
+		defer airbrakehandler.RecoverPanic(handler, airbrakehandler.FlushSync(true))
+
+		panic(errors.New("boom"))
+	}()
+
+	context, _ := notice["context"].(map[string]interface{})
+	assert.Equal(t, "critical", context["severity"])
+}
+
+func TestRecoverPanic_String(t *testing.T) {
+	var notice map[string]interface{}
+
+	server := notifyServer(t, &notice)
+	defer server.Close()
+
+	handler := airbrakehandler.New(1, "key", airbrakehandler.Host(server.URL))
+
+	func() {
+		defer airbrakehandler.RecoverPanic(handler, airbrakehandler.FlushSync(true))
+
+		panic("boom")
+	}()
+
+	errs, _ := notice["errors"].([]interface{})
+	assert.NotEmpty(t, errs)
+
+	first, _ := errs[0].(map[string]interface{})
+	assert.Equal(t, "boom", first["message"])
+
+	context, _ := notice["context"].(map[string]interface{})
+	assert.Equal(t, "critical", context["severity"])
+}
+
+func TestHandler_IgnoreFunc(t *testing.T) {
+	var called bool
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id":"1"}`))
+	}))
+	defer server.Close()
+
+	handler := airbrakehandler.New(
+		1,
+		"key",
+		airbrakehandler.Host(server.URL),
+		airbrakehandler.IgnoreFunc(func(err error) bool {
+			return strings.Contains(err.Error(), "ignore me")
+		}),
+	)
+
+	handler.Handle(errors.New("please ignore me"))
+
+	assert.False(t, called)
+}
+
+func TestHandler_SeverityFunc(t *testing.T) {
+	var notice map[string]interface{}
+
+	server := notifyServer(t, &notice)
+	defer server.Close()
+
+	handler := airbrakehandler.New(
+		1,
+		"key",
+		airbrakehandler.Host(server.URL),
+		airbrakehandler.SeverityFunc(func(err error) string {
+			if strings.Contains(err.Error(), "fatal") {
+				return "critical"
+			}
+
+			return ""
+		}),
+	)
+
+	handler.Handle(errors.New("fatal disk error"))
+
+	context, _ := notice["context"].(map[string]interface{})
+	assert.Equal(t, "critical", context["severity"])
+}
+
+func TestHandler_ParamsFilter(t *testing.T) {
+	var notice map[string]interface{}
+
+	server := notifyServer(t, &notice)
+	defer server.Close()
+
+	handler := airbrakehandler.New(
+		1,
+		"key",
+		airbrakehandler.Host(server.URL),
+		airbrakehandler.ParamsFilter{"authorization", "password"},
+	)
+
+	err := emperror.With(
+		errors.New("request failed"),
+		"authorization", "Bearer s3cr3t",
+		"password", "hunter2",
+		"user_id", 42,
+	)
+
+	handler.Handle(err)
+
+	params, _ := notice["params"].(map[string]interface{})
+	assert.NotContains(t, params, "authorization")
+	assert.NotContains(t, params, "password")
+	assert.EqualValues(t, 42, params["user_id"])
+
+	body, err2 := json.Marshal(notice)
+	assert.NoError(t, err2)
+	assert.NotContains(t, string(body), "s3cr3t")
+	assert.NotContains(t, string(body), "hunter2")
+}
+
+func TestHandler_Environment(t *testing.T) {
+	var notice map[string]interface{}
+
+	server := notifyServer(t, &notice)
+	defer server.Close()
+
+	handler := airbrakehandler.New(
+		1,
+		"key",
+		airbrakehandler.Host(server.URL),
+		airbrakehandler.Environment("production"),
+		airbrakehandler.Revision("1234567"),
+	)
+
+	handler.Handle(errors.New("boom"))
+
+	context, _ := notice["context"].(map[string]interface{})
+	assert.Equal(t, "production", context["environment"])
+	assert.Equal(t, "1234567", context["revision"])
+}
+
+func TestHandler_NotifyDeploy(t *testing.T) {
+	var path string
+	var body map[string]string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.RequestURI()
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	handler := airbrakehandler.New(1, "key", airbrakehandler.Host(server.URL))
+
+	err := handler.NotifyDeploy("production", "1234567", "github.com/goph/emperror", "john.doe")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "/api/v4/projects/1/deploys?key=key", path)
+	assert.Equal(t, "production", body["environment"])
+	assert.Equal(t, "1234567", body["revision"])
+	assert.Equal(t, "github.com/goph/emperror", body["repository"])
+	assert.Equal(t, "john.doe", body["username"])
+}
+
+func TestHandler_NotifyDeploy_DefaultsFromHandler(t *testing.T) {
+	var body map[string]string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	handler := airbrakehandler.New(
+		1,
+		"key",
+		airbrakehandler.Host(server.URL),
+		airbrakehandler.Environment("staging"),
+		airbrakehandler.Revision("abcdef0"),
+		airbrakehandler.Repository("github.com/goph/emperror"),
+	)
+
+	// Leaving env/rev/repo empty falls back to the handler's configured options.
+	assert.NoError(t, handler.NotifyDeploy("", "", "", "jane.doe"))
+
+	assert.Equal(t, "staging", body["environment"])
+	assert.Equal(t, "abcdef0", body["revision"])
+	assert.Equal(t, "github.com/goph/emperror", body["repository"])
+	assert.Equal(t, "jane.doe", body["username"])
+}
+
+func TestHandler_NotifyDeploy_NonSuccessStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	handler := airbrakehandler.New(1, "key", airbrakehandler.Host(server.URL))
+
+	err := handler.NotifyDeploy("production", "1234567", "github.com/goph/emperror", "john.doe")
+
+	assert.Error(t, err)
+}
+
+func TestRecoverPanic_NoPanic(t *testing.T) {
+	handler := airbrakehandler.New(1, "key")
+
+	assert.NotPanics(t, func() {
+		defer airbrakehandler.RecoverPanic(handler)
+	})
+}
+
+func TestHandler_Async(t *testing.T) {
+	var mu sync.Mutex
+	received := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		received++
+		mu.Unlock()
+
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id":"1"}`))
+	}))
+	defer server.Close()
+
+	handler := airbrakehandler.New(
+		1,
+		"key",
+		airbrakehandler.Host(server.URL),
+		airbrakehandler.Async(2, 10),
+	)
+
+	for i := 0; i < 5; i++ {
+		handler.Handle(errors.New("boom"))
+	}
+
+	assert.NoError(t, handler.Close())
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 5, received)
+	assert.EqualValues(t, 5, handler.Stats().Sent)
+}
+
+// TestHandler_Async_NegativeQueueSizeDoesNotPanic guards against make(chan, -1) panicking
+// the worker pool on a negative queueSize.
+func TestHandler_Async_NegativeQueueSizeDoesNotPanic(t *testing.T) {
+	var notice map[string]interface{}
+
+	server := notifyServer(t, &notice)
+	defer server.Close()
+
+	var handler *airbrakehandler.Handler
+
+	assert.NotPanics(t, func() {
+		handler = airbrakehandler.New(
+			1,
+			"key",
+			airbrakehandler.Host(server.URL),
+			airbrakehandler.Async(1, -1),
+		)
+	})
+
+	handler.Handle(errors.New("boom"))
+
+	assert.NoError(t, handler.Close())
+}
+
+// TestHandler_Async_NonPositiveWorkersStillStartsPool guards against a non-positive workers
+// count silently disabling the worker pool (and, with it, the backpressure Async exists for).
+func TestHandler_Async_NonPositiveWorkersStillStartsPool(t *testing.T) {
+	var notice map[string]interface{}
+
+	server := notifyServer(t, &notice)
+	defer server.Close()
+
+	handler := airbrakehandler.New(
+		1,
+		"key",
+		airbrakehandler.Host(server.URL),
+		airbrakehandler.Async(0, 5),
+	)
+
+	handler.Handle(errors.New("boom"))
+
+	assert.NoError(t, handler.Close())
+	assert.EqualValues(t, 1, handler.Stats().Sent)
+}
+
+func TestHandler_Async_OnDrop(t *testing.T) {
+	blocked := make(chan struct{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-blocked
+
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id":"1"}`))
+	}))
+	defer server.Close()
+
+	var dropped int32
+	var mu sync.Mutex
+
+	handler := airbrakehandler.New(
+		1,
+		"key",
+		airbrakehandler.Host(server.URL),
+		airbrakehandler.Async(1, 1),
+		airbrakehandler.OnDrop(func(err error) {
+			mu.Lock()
+			dropped++
+			mu.Unlock()
+		}),
+	)
+
+	for i := 0; i < 5; i++ {
+		handler.Handle(errors.New("boom"))
+	}
+
+	close(blocked)
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		mu.Lock()
+		n := dropped
+		mu.Unlock()
+
+		if n > 0 || time.Now().After(deadline) {
+			assert.True(t, n > 0, "expected at least one notice to be dropped")
+
+			break
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	_ = handler.Close()
+}